@@ -0,0 +1,105 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tpl provides the template functions and the types shared between
+// the template engine implementation in tplimpl and its callers.
+package tpl
+
+// Info holds information about a template gathered while parsing it, e.g.
+// whether it has an {{ .Inner }} call (shortcodes) and its inline
+// configuration, if any.
+type Info struct {
+	IsInner bool
+	Config  Config
+}
+
+// Config holds the optional inline template configuration, set via
+// {{ $_hugo_config := `{ "version": 42 }` }} near the top of the template.
+type Config struct {
+	Version int
+}
+
+// DefaultConfig is the Config used for templates without an inline
+// configuration block.
+var DefaultConfig = Config{}
+
+// TemplateExecutor is implemented by the templates handed out by
+// TemplateHandler's Lookup.
+type TemplateExecutor interface {
+	ExecuteToString(data interface{}) (string, error)
+}
+
+// TemplateFinder finds templates by name.
+type TemplateFinder interface {
+	Lookup(name string) (TemplateExecutor, bool)
+}
+
+// TemplateHandler manages the parsing and execution of all of a site's
+// templates.
+type TemplateHandler interface {
+	TemplateFinder
+
+	// AddTemplate parses and adds a template to the store with the given
+	// name.
+	AddTemplate(name, tpl string) error
+
+	// MarkReady is called once all templates are added, running any
+	// transformation that needs the full set of templates to be in place
+	// (e.g. resolving template dependencies for the Params case-insensitivity
+	// rewrite).
+	MarkReady() error
+
+	// Diagnostics returns what the AST transformations did to the named
+	// template, or nil if name is unknown. It backs hugo --debug-templates;
+	// see TransformDiagnostics.
+	Diagnostics(name string) *TransformDiagnostics
+}
+
+// TransformReason categorizes a single rewrite TransformDiagnostics
+// recorded for a template.
+type TransformReason string
+
+const (
+	// ReasonParamsLowercase marks a .Params key rewritten to its
+	// lower-cased form.
+	ReasonParamsLowercase TransformReason = "params-lowercase"
+	// ReasonIsZeroInsert marks an if/with condition (or and/or/not
+	// argument) wrapped in the zero-value-aware truth check.
+	ReasonIsZeroInsert TransformReason = "isZero-insert"
+	// ReasonInnerDetection marks a bare .Inner access, used to detect
+	// that a shortcode template renders its inner content.
+	ReasonInnerDetection TransformReason = "inner-detection"
+	// ReasonConfigExtraction marks a $_hugo_config JSON block parsed out
+	// of the template.
+	ReasonConfigExtraction TransformReason = "config-extraction"
+)
+
+// Transform records one rewrite applyTransformations performed on a
+// template's parse tree.
+type Transform struct {
+	// Pos is the byte offset of the original node in the template source.
+	Pos int
+	// Original is a textual rendering of the node before the rewrite.
+	Original string
+	// Rewritten is a textual rendering of the node after the rewrite, or
+	// empty when nothing was substituted (e.g. ReasonInnerDetection,
+	// which only flips a flag).
+	Rewritten string
+	Reason    TransformReason
+}
+
+// TransformDiagnostics is the set of rewrites applyTransformations
+// performed on a single template.
+type TransformDiagnostics struct {
+	Transforms []Transform
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tplimpl
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/gohugoio/hugo/tpl"
+)
+
+// builtinFuncs are added to every template this package creates, regardless
+// of whether it ends up being parsed as HTML or plain text. They back the
+// rewrites applyTransformations performs on the parse tree, so they must be
+// present before the templates are executed.
+var builtinFuncs = map[string]interface{}{
+	hugoCondFuncName:         hugoCond,
+	hugoStrictParamsFuncName: hugoStrictParams,
+}
+
+// executor adapts either an *html/template.Template or an
+// *text/template.Template to tpl.TemplateExecutor.
+type executor struct {
+	name string
+	// Exactly one of these is set.
+	h *htmltemplate.Template
+	t *texttemplate.Template
+}
+
+func (te *executor) ExecuteToString(data interface{}) (string, error) {
+	var b bytes.Buffer
+	var err error
+	if te.h != nil {
+		err = te.h.Execute(&b, data)
+	} else {
+		err = te.t.Execute(&b, data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", te.name, err)
+	}
+	return b.String(), nil
+}
+
+// templateHandler is a minimal implementation of tpl.TemplateHandler. Text
+// templates (used for plain text output formats) are recognised by the
+// "_text/" name prefix, mirroring how Hugo picks the output format's
+// template engine elsewhere in the codebase.
+type templateHandler struct {
+	opts []templateContextOption
+
+	html *htmltemplate.Template
+	text *texttemplate.Template
+
+	diagnostics map[string]*tpl.TransformDiagnostics
+}
+
+// New creates a tpl.TemplateHandler. opts, if given, are applied to every
+// template's templateContext, e.g. to opt a site out of the
+// ParamsKeysToLower rewrite. The returned handler always registers
+// builtinFuncs, so it enables the isZero rewrite by default; pass
+// withInsertIsZeroFunc(false) to turn it back off.
+func New(opts ...templateContextOption) tpl.TemplateHandler {
+	allOpts := append([]templateContextOption{withInsertIsZeroFunc(true)}, opts...)
+	return &templateHandler{
+		opts:        allOpts,
+		html:        htmltemplate.New("").Funcs(builtinFuncs),
+		text:        texttemplate.New("").Funcs(builtinFuncs),
+		diagnostics: make(map[string]*tpl.TransformDiagnostics),
+	}
+}
+
+func (t *templateHandler) isText(name string) bool {
+	return strings.HasPrefix(name, "_text/")
+}
+
+func (t *templateHandler) AddTemplate(name, tplStr string) error {
+	if t.isText(name) {
+		_, err := t.text.New(name).Parse(tplStr)
+		return err
+	}
+
+	_, err := t.html.New(name).Parse(tplStr)
+	return err
+}
+
+// MarkReady runs applyTemplateTransformers over every template added so
+// far. It has to happen here, once the full set is known, rather than in
+// AddTemplate: a {{ template "X" }}/{{ block "X" }} reference to a
+// template defined by a file added later would otherwise have its
+// lookupFn("X") come back nil, and that referenced tree would never get
+// transformed at all.
+func (t *templateHandler) MarkReady() error {
+	for _, tt := range t.text.Templates() {
+		if tt.Tree == nil {
+			// The nameless root template created by New("") itself; it's
+			// never the target of a New(name).Parse call.
+			continue
+		}
+		c, err := applyTemplateTransformers(templateUndefined, tt.Tree, createParseTreeLookupText(t.text), t.opts...)
+		if err != nil {
+			return err
+		}
+		t.diagnostics[tt.Name()] = c.Diagnostics
+	}
+
+	for _, tt := range t.html.Templates() {
+		if tt.Tree == nil {
+			continue
+		}
+		c, err := applyTemplateTransformers(templateUndefined, tt.Tree, createParseTreeLookup(t.html), t.opts...)
+		if err != nil {
+			return err
+		}
+		t.diagnostics[tt.Name()] = c.Diagnostics
+	}
+
+	return nil
+}
+
+// Diagnostics returns what the AST transformations did to the named
+// template, or nil if name is unknown. It backs hugo --debug-templates.
+func (t *templateHandler) Diagnostics(name string) *tpl.TransformDiagnostics {
+	return t.diagnostics[name]
+}
+
+func (t *templateHandler) Lookup(name string) (tpl.TemplateExecutor, bool) {
+	if t.isText(name) {
+		tt := t.text.Lookup(name)
+		if tt == nil {
+			return nil, false
+		}
+		return &executor{name: name, t: tt}, true
+	}
+
+	tt := t.html.Lookup(name)
+	if tt == nil {
+		return nil, false
+	}
+	return &executor{name: name, h: tt}, true
+}
+
+// createParseTreeLookupText is the *text/template.Template counterpart of
+// createParseTreeLookup.
+func createParseTreeLookupText(templ *texttemplate.Template) func(name string) *parse.Tree {
+	return func(name string) *parse.Tree {
+		tt := templ.Lookup(name)
+		if tt == nil {
+			return nil
+		}
+		return tt.Tree
+	}
+}
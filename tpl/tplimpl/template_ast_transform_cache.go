@@ -0,0 +1,85 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tplimpl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"text/template/parse"
+
+	"github.com/gohugoio/hugo/tpl"
+)
+
+// transformedTree is what the cache stores for a given pre-transform tree:
+// the rewritten root, ready to be deep-copied onto a clone, plus the
+// tpl.Info collected while walking it.
+type transformedTree struct {
+	root        *parse.ListNode
+	info        tpl.Info
+	diagnostics *tpl.TransformDiagnostics
+}
+
+// templateTransformCache caches the result of applyTransformations, keyed
+// by a hash of the untransformed parse tree (plus whatever options affect
+// the outcome). It makes repeat transformations of the same template
+// source -- the common case once a site has cloned its base templates
+// once per page -- a map lookup and a node copy instead of a full AST
+// walk.
+//
+// It is safe for concurrent use, as required by the template handler,
+// which may build several sites' templates in parallel.
+type templateTransformCache struct {
+	mu sync.RWMutex
+	m  map[string]transformedTree
+}
+
+func newTemplateTransformCache() *templateTransformCache {
+	return &templateTransformCache{m: make(map[string]transformedTree)}
+}
+
+// globalTemplateTransformCache is shared across every templateHandler in
+// the process. Its keys already fold in the transform options, so sharing
+// it across sites with different configurations is safe.
+var globalTemplateTransformCache = newTemplateTransformCache()
+
+func (c *templateTransformCache) get(key string) (transformedTree, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *templateTransformCache) set(key string, v transformedTree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = v
+}
+
+func (c *templateTransformCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.m)
+}
+
+// transformCacheKey identifies tree for caching purposes: its textual
+// content (a cheap stand-in for a structural hash -- two trees that
+// reference no other template print identically if and only if they'd be
+// walked identically; trees that do are not cached at all) plus the
+// options that influence how applyTransformations rewrites it.
+func transformCacheKey(typ templateType, tree *parse.Tree, c *templateContext) string {
+	h := sha256.Sum256([]byte(tree.Root.String()))
+	return fmt.Sprintf("%d|%t|%t|%t|%s", typ, c.caseSensitiveParams, c.strictParams, c.insertIsZeroFunc, hex.EncodeToString(h[:]))
+}
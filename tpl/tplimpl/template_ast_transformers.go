@@ -0,0 +1,705 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tplimpl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/gohugoio/hugo/tpl"
+)
+
+// templateType describes the broader category a template belongs to. It
+// affects which transformations applyTransformations will consider, e.g.
+// only partials look for {{ return ... }}.
+type templateType int
+
+const (
+	templateUndefined templateType = iota
+	templateShortcode
+	templatePartial
+)
+
+// hugoCondFuncName is the name of the function inserted into if/with
+// conditions (and and/or/not arguments) to work around text/template's
+// reflect-based truth test, which considers any non-nil struct or
+// interface value "true" even if it is the zero value (e.g. a zero
+// time.Time, see issue #5865).
+const hugoCondFuncName = "__htH_cond"
+
+// decl maps a declared variable (its name, e.g. "$site") to the identifier
+// chain it resolves to relative to the template's root context, e.g.
+// "$siteParams" declared via {{ $siteParams := .Site.Params }} resolves to
+// []string{"Site", "Params"}.
+type decl map[string][]string
+
+// indexOfReplacementStart returns the index into idents from which the
+// remaining identifiers are treated as user-supplied parameter keys and
+// therefore looked up case-insensitively, or -1 if idents does not
+// represent a *.Params access at all.
+//
+// Keys living below Site.Data (and by extension any variable holding a
+// chain through it) are explicitly excluded: those come from arbitrary
+// data files and must keep whatever case the author used.
+func (d decl) indexOfReplacementStart(idents []string) int {
+	for i, ident := range idents {
+		if strings.EqualFold(ident, "Params") {
+			if i > 0 && strings.EqualFold(idents[i-1], "Data") {
+				return -1
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// templateContextOption configures a templateContext. See
+// withCaseSensitiveParams and withStrictParams.
+type templateContextOption func(*templateContext)
+
+// templateContext holds the state needed while walking and rewriting a
+// single parsed template tree.
+type templateContext struct {
+	decl     decl
+	lookupFn func(name string) *parse.Tree
+
+	typ templateType
+
+	// Info is populated as a side effect of applyTransformations.
+	Info tpl.Info
+
+	// Diagnostics records every rewrite applyTransformations performs, so
+	// callers can explain to a template author exactly what happened to
+	// their template; see tpl.TransformDiagnostics.
+	Diagnostics *tpl.TransformDiagnostics
+
+	// caseSensitiveParams disables the default behaviour of rewriting
+	// .Params.FOO lookups to .Params.foo. It is an explicit per-site/
+	// per-template opt-out for projects whose front matter mirrors
+	// externally defined, case-sensitive keys.
+	caseSensitiveParams bool
+
+	// strictParams makes a .Params.FOO access fail at execution time,
+	// instead of silently returning the zero value, when its lower-cased
+	// form, the key the rewrite looks up, matches no actual key. It only has an effect
+	// when caseSensitiveParams is false, since that's the only case
+	// where a rewrite is attempted in the first place.
+	strictParams bool
+
+	// insertIsZeroFunc enables rewriting if/with conditions (and and/or/
+	// not arguments) to go through hugoCond instead of relying on
+	// text/template's own, occasionally wrong, truth test (issue #5865).
+	// It is only turned on by code paths that are guaranteed to have
+	// registered hugoCondFuncName (and hugoStrictParamsFuncName, for
+	// strict mode) in the template's function map; tests that parse a
+	// bare template by hand don't, and enabling it there would make
+	// execution fail with "function not defined".
+	insertIsZeroFunc bool
+
+	// templatesOnStack tracks the names of the templates currently being
+	// walked into via a {{ template }}/{{ block }} reference, so that a
+	// template which (directly or transitively) references itself is
+	// transformed only once per branch instead of recursing forever
+	// (issue #2927).
+	templatesOnStack map[string]bool
+}
+
+func withCaseSensitiveParams(enabled bool) templateContextOption {
+	return func(c *templateContext) {
+		c.caseSensitiveParams = enabled
+	}
+}
+
+func withStrictParams(enabled bool) templateContextOption {
+	return func(c *templateContext) {
+		c.strictParams = enabled
+	}
+}
+
+func withInsertIsZeroFunc(enabled bool) templateContextOption {
+	return func(c *templateContext) {
+		c.insertIsZeroFunc = enabled
+	}
+}
+
+func newTemplateContext(lookupFn func(name string) *parse.Tree, opts ...templateContextOption) *templateContext {
+	c := &templateContext{
+		decl:             make(decl),
+		lookupFn:         lookupFn,
+		templatesOnStack: make(map[string]bool),
+		Info:             tpl.Info{Config: tpl.DefaultConfig},
+		Diagnostics:      &tpl.TransformDiagnostics{},
+	}
+	// "$" always refers to the root data context.
+	c.decl["$"] = []string{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// record appends a rewrite to c.Diagnostics. pos is the position of the
+// node before it was touched, as returned by its Position() method.
+func (c *templateContext) record(pos parse.Pos, original, rewritten string, reason tpl.TransformReason) {
+	c.Diagnostics.Transforms = append(c.Diagnostics.Transforms, tpl.Transform{
+		Pos:       int(pos),
+		Original:  original,
+		Rewritten: rewritten,
+		Reason:    reason,
+	})
+}
+
+// createParseTreeLookup returns a function that resolves the parse tree of
+// any template associated with templ, by name.
+func createParseTreeLookup(templ *template.Template) func(name string) *parse.Tree {
+	return func(name string) *parse.Tree {
+		tt := templ.Lookup(name)
+		if tt == nil {
+			return nil
+		}
+		return tt.Tree
+	}
+}
+
+// applyTemplateTransformers parses no template itself; it walks tree and
+// returns the templateContext collected along the way, or an error if tree
+// is nil. It goes through globalTemplateTransformCache; see
+// applyTemplateTransformersCached for a variant that takes an explicit
+// cache, useful for tests that want to assert on cache population without
+// racing every other test in the package over the shared global.
+func applyTemplateTransformers(typ templateType, tree *parse.Tree, lookupFn func(name string) *parse.Tree, opts ...templateContextOption) (*templateContext, error) {
+	return applyTemplateTransformersCached(globalTemplateTransformCache, typ, tree, lookupFn, opts...)
+}
+
+func applyTemplateTransformersCached(cache *templateTransformCache, typ templateType, tree *parse.Tree, lookupFn func(name string) *parse.Tree, opts ...templateContextOption) (*templateContext, error) {
+	if tree == nil {
+		return nil, errors.New("expected a parsed template tree, got nil")
+	}
+
+	// A tree that references other templates via {{ template }}/{{ block }}
+	// is walked together with them, and they are not part of the key, so
+	// such a tree is never stored in the cache.
+	referencesTemplates := false
+	if lookupFn != nil {
+		lookup := lookupFn
+		lookupFn = func(name string) *parse.Tree {
+			referencesTemplates = true
+			return lookup(name)
+		}
+	}
+
+	c := newTemplateContext(lookupFn, opts...)
+	c.typ = typ
+
+	key := transformCacheKey(typ, tree, c)
+	if cached, ok := cache.get(key); ok {
+		tree.Root = cached.root.CopyList()
+		c.Info = cached.info
+		c.Diagnostics = cached.diagnostics
+		return c, nil
+	}
+
+	c.applyTransformations(tree.Root)
+
+	if !referencesTemplates {
+		cache.set(key, transformedTree{root: tree.Root.CopyList(), info: c.Info, diagnostics: c.Diagnostics})
+	}
+
+	return c, nil
+}
+
+// applyTransformations walks n, rewriting .Params accesses to their
+// lower-cased form (unless caseSensitiveParams is set), inserting the
+// zero-value-aware truth check around if/with/and/or/not conditions, and
+// collecting tpl.Info as it goes.
+func (c *templateContext) applyTransformations(n parse.Node) parse.Node {
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x != nil {
+			c.applyTransformationsToNodes(x.Nodes...)
+		}
+	case *parse.ActionNode:
+		c.applyTransformations(x.Pipe)
+	case *parse.IfNode:
+		c.handleConditionalPipe(x.Pipe)
+		c.applyTransformations(x.List)
+		c.applyTransformations(x.ElseList)
+	case *parse.WithNode:
+		c.handleConditionalPipe(x.Pipe)
+		c.applyTransformations(x.List)
+		c.applyTransformations(x.ElseList)
+	case *parse.RangeNode:
+		c.applyTransformations(x.Pipe)
+		c.applyTransformations(x.List)
+		c.applyTransformations(x.ElseList)
+	case *parse.TemplateNode:
+		if x.Pipe != nil {
+			c.applyTransformations(x.Pipe)
+		}
+		c.applyTransformationsToAssociatedTemplate(x.Name)
+	case *parse.PipeNode:
+		if x == nil {
+			return n
+		}
+		for _, cmd := range x.Cmds {
+			c.applyTransformations(cmd)
+		}
+		if len(x.Decl) == 1 && !x.IsAssign {
+			c.handleDecl(x)
+		}
+	case *parse.CommandNode:
+		c.handleCommand(x)
+	case *parse.FieldNode:
+		c.handleFieldNode(x)
+	case *parse.ChainNode:
+		c.handleChainNode(x)
+	case *parse.VariableNode:
+		c.handleVariableNode(x)
+	}
+
+	return n
+}
+
+func (c *templateContext) applyTransformationsToNodes(nodes ...parse.Node) {
+	for _, node := range nodes {
+		c.applyTransformations(node)
+	}
+}
+
+// applyTransformationsToAssociatedTemplate walks the tree of the template
+// registered under name, if any, so that {{ block "main" . }}/
+// {{ template "main" . }} references get the same rewrites as the
+// referencing template itself -- this is how a block's body (typically
+// supplied by an overlay template in Hugo's layout lookup) picks up the
+// .Params lower-casing and the rest of the transformations applied to its
+// base. name is tracked on c.templatesOnStack for the duration of the walk
+// so that a template which references itself, directly or transitively,
+// is only ever transformed once per branch (issue #2927).
+func (c *templateContext) applyTransformationsToAssociatedTemplate(name string) {
+	if c.lookupFn == nil || c.templatesOnStack[name] {
+		return
+	}
+
+	tree := c.lookupFn(name)
+	if tree == nil || tree.Root == nil {
+		return
+	}
+
+	c.templatesOnStack[name] = true
+	c.applyTransformations(tree.Root)
+	delete(c.templatesOnStack, name)
+}
+
+// handleDecl records the identifier chain a freshly declared variable
+// resolves to, so later accesses through that variable can be rewritten as
+// if they went through the original chain. It also handles the two
+// template-author-facing conventions this package understands: .Inner
+// (shortcodes) and the $_hugo_config JSON blob.
+func (c *templateContext) handleDecl(pipe *parse.PipeNode) {
+	name := pipe.Decl[0].Ident[0]
+
+	if name == "$_hugo_config" && len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		if s, ok := pipe.Cmds[0].Args[0].(*parse.StringNode); ok {
+			var conf tpl.Config
+			if err := json.Unmarshal([]byte(s.Text), &conf); err == nil {
+				c.Info.Config = conf
+				c.record(s.Position(), s.Text, "", tpl.ReasonConfigExtraction)
+			}
+		}
+		return
+	}
+
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		delete(c.decl, name)
+		return
+	}
+
+	if chain, ok := c.resolveChain(pipe.Cmds[0].Args[0]); ok {
+		c.decl[name] = chain
+	} else {
+		delete(c.decl, name)
+	}
+}
+
+// resolveChain returns the identifier chain n resolves to, relative to the
+// template's root context, if n is a simple reference (".", a field chain,
+// a known variable, or a call to one of the global functions that behave
+// like the root context, e.g. site).
+func (c *templateContext) resolveChain(n parse.Node) ([]string, bool) {
+	switch x := n.(type) {
+	case *parse.DotNode:
+		return []string{}, true
+	case *parse.FieldNode:
+		return x.Ident, true
+	case *parse.ChainNode:
+		if ident, ok := x.Node.(*parse.IdentifierNode); ok && isGlobalParamsFunc(ident.Ident) {
+			return x.Field, true
+		}
+	case *parse.IdentifierNode:
+		if isGlobalParamsFunc(x.Ident) {
+			return []string{}, true
+		}
+	case *parse.VariableNode:
+		if prefix, ok := c.decl[x.Ident[0]]; ok {
+			return append(append([]string{}, prefix...), x.Ident[1:]...), true
+		}
+	}
+	return nil, false
+}
+
+// isGlobalParamsFunc reports whether name is a built-in that returns
+// something with the same shape as the page/site context, so that a chain
+// through it (e.g. site.Params.FOO) is eligible for the same case-folding
+// as .Site.Params.FOO.
+func isGlobalParamsFunc(name string) bool {
+	return name == "site"
+}
+
+func (c *templateContext) handleCommand(cmd *parse.CommandNode) {
+	if len(cmd.Args) == 0 {
+		return
+	}
+
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		switch ident.Ident {
+		case "return":
+			// Only meaningful in partials; nothing further to do here
+			// beyond making sure the return value itself is transformed
+			// below.
+		case "not", "and", "or":
+			for i := 1; i < len(cmd.Args); i++ {
+				cmd.Args[i] = c.wrapInIsZeroCheck(cmd.Args[i])
+			}
+		}
+	}
+
+	for i, arg := range cmd.Args {
+		switch arg.(type) {
+		case *parse.FieldNode, *parse.ChainNode, *parse.VariableNode:
+			c.applyTransformations(arg)
+			cmd.Args[i] = c.maybeWrapStrictParamsCheck(arg)
+		case *parse.PipeNode:
+			c.applyTransformations(arg)
+		}
+	}
+}
+
+// handleConditionalPipe wraps the value an if/with condition evaluates to
+// in the zero-aware truth check, then continues the normal walk so any
+// .Params accesses inside are still rewritten.
+func (c *templateContext) handleConditionalPipe(pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		c.applyTransformations(cmd)
+	}
+	if len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		arg := c.maybeWrapStrictParamsCheck(pipe.Cmds[0].Args[0])
+		pipe.Cmds[0].Args[0] = c.wrapInIsZeroCheck(arg)
+	}
+}
+
+// unwrapSingleArgPipe returns the sole argument of a single-command,
+// single-argument PipeNode -- the shape text/template parses a
+// parenthesized condition like "(.TimeZero)" into -- and true. It returns
+// n and false for anything else, including multi-command/multi-arg
+// pipelines, which aren't a simple value reference to unwrap.
+func unwrapSingleArgPipe(n parse.Node) (parse.Node, bool) {
+	pipe, ok := n.(*parse.PipeNode)
+	if !ok || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return n, false
+	}
+	return pipe.Cmds[0].Args[0], true
+}
+
+// maybeWrapStrictParamsCheck replaces a dot-rooted .Params field access
+// with a call that performs the same (lower-cased) key lookup at
+// execution time, erroring out if no such key exists. It is a no-op
+// unless strictParams is enabled, caseSensitiveParams is not, and arg is
+// in fact a rewritten .Params access.
+//
+// Variable- and global-func-rooted Params chains (e.g. $siteParams.Foo,
+// site.Params.Foo) are intentionally left alone; they are rarer in
+// practice and the field-chain case covers the common typo this mode is
+// meant to catch.
+func (c *templateContext) maybeWrapStrictParamsCheck(arg parse.Node) parse.Node {
+	if c.caseSensitiveParams || !c.strictParams {
+		return arg
+	}
+
+	target := arg
+	if inner, ok := unwrapSingleArgPipe(arg); ok {
+		target = inner
+	}
+
+	fn, ok := target.(*parse.FieldNode)
+	if !ok {
+		return arg
+	}
+
+	start := c.decl.indexOfReplacementStart(fn.Ident)
+	if start < 0 {
+		return arg
+	}
+
+	args := []parse.Node{
+		&parse.IdentifierNode{NodeType: parse.NodeIdentifier, Ident: hugoStrictParamsFuncName},
+		&parse.FieldNode{NodeType: parse.NodeField, Ident: append([]string{}, fn.Ident[:start]...)},
+	}
+	for _, key := range fn.Ident[start:] {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Text: key, Quoted: strconv.Quote(key)})
+	}
+
+	cmd := &parse.CommandNode{NodeType: parse.NodeCommand, Args: args}
+
+	// A bare CommandNode isn't a case text/template's evalCommand knows how
+	// to evaluate when it's sitting in another command's Args; it needs to
+	// be wrapped the way "(f .X)" itself parses, as a single-command
+	// PipeNode, or execution fails with "can't evaluate command".
+	return &parse.PipeNode{NodeType: parse.NodePipe, Cmds: []*parse.CommandNode{cmd}}
+}
+
+// wrapInIsZeroCheck wraps a simple value reference in a call to
+// hugoCondFuncName so it is evaluated for truthiness correctly even when
+// reflection would otherwise consider it non-zero (structs, typed nil
+// pointers stored in a non-nil interface). A parenthesized reference like
+// "(.TimeZero)" is unwrapped first, since it parses as a single-command
+// PipeNode rather than the bare node the type switch below looks for.
+// Anything that is already a function call, or that isn't transformable,
+// is returned unchanged.
+func (c *templateContext) wrapInIsZeroCheck(arg parse.Node) parse.Node {
+	if !c.insertIsZeroFunc {
+		return arg
+	}
+
+	target := arg
+	if inner, ok := unwrapSingleArgPipe(arg); ok {
+		target = inner
+	}
+
+	switch target.(type) {
+	case *parse.FieldNode, *parse.ChainNode, *parse.VariableNode, *parse.DotNode:
+	default:
+		return arg
+	}
+
+	cmd := &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Args: []parse.Node{
+			&parse.IdentifierNode{NodeType: parse.NodeIdentifier, Ident: hugoCondFuncName},
+			target,
+		},
+	}
+
+	// See the matching comment in maybeWrapStrictParamsCheck: this needs to
+	// be a PipeNode, not a bare CommandNode, to be a valid Args element.
+	rewritten := &parse.PipeNode{NodeType: parse.NodePipe, Cmds: []*parse.CommandNode{cmd}}
+
+	c.record(arg.Position(), arg.String(), rewritten.String(), tpl.ReasonIsZeroInsert)
+
+	return rewritten
+}
+
+// handleFieldNode lower-cases the trailing .Params keys of a dot-rooted
+// field chain (e.g. .Site.Params.Foo) in place, and records a bare .Inner
+// access (used by shortcode templates).
+func (c *templateContext) handleFieldNode(x *parse.FieldNode) {
+	if len(x.Ident) == 1 && x.Ident[0] == "Inner" {
+		c.Info.IsInner = true
+		c.record(x.Position(), ".Inner", "", tpl.ReasonInnerDetection)
+	}
+
+	if c.caseSensitiveParams {
+		return
+	}
+
+	start := c.decl.indexOfReplacementStart(x.Ident)
+	if start < 0 {
+		return
+	}
+
+	original := "." + strings.Join(x.Ident, ".")
+	for i := start; i < len(x.Ident); i++ {
+		x.Ident[i] = strings.ToLower(x.Ident[i])
+	}
+	if rewritten := "." + strings.Join(x.Ident, "."); rewritten != original {
+		c.record(x.Position(), original, rewritten, tpl.ReasonParamsLowercase)
+	}
+}
+
+func (c *templateContext) handleChainNode(x *parse.ChainNode) {
+	ident, ok := x.Node.(*parse.IdentifierNode)
+	if !ok || !isGlobalParamsFunc(ident.Ident) {
+		c.applyTransformations(x.Node)
+		return
+	}
+
+	if c.caseSensitiveParams {
+		return
+	}
+
+	start := c.decl.indexOfReplacementStart(x.Field)
+	if start < 0 {
+		return
+	}
+
+	original := ident.Ident + "." + strings.Join(x.Field, ".")
+	for i := start; i < len(x.Field); i++ {
+		x.Field[i] = strings.ToLower(x.Field[i])
+	}
+	if rewritten := ident.Ident + "." + strings.Join(x.Field, "."); rewritten != original {
+		c.record(x.Position(), original, rewritten, tpl.ReasonParamsLowercase)
+	}
+}
+
+func (c *templateContext) handleVariableNode(x *parse.VariableNode) {
+	if len(x.Ident) < 2 {
+		return
+	}
+
+	prefix, ok := c.decl[x.Ident[0]]
+	if !ok {
+		return
+	}
+
+	own := x.Ident[1:]
+	full := append(append([]string{}, prefix...), own...)
+
+	if len(own) == 1 && own[0] == "Inner" && len(prefix) == 0 {
+		c.Info.IsInner = true
+		c.record(x.Position(), x.Ident[0]+".Inner", "", tpl.ReasonInnerDetection)
+	}
+
+	if c.caseSensitiveParams {
+		return
+	}
+
+	start := c.decl.indexOfReplacementStart(full)
+	if start < 0 {
+		return
+	}
+
+	offset := start - len(prefix)
+	if offset < 0 {
+		offset = 0
+	}
+
+	original := x.Ident[0] + "." + strings.Join(own, ".")
+	for i := offset; i < len(own); i++ {
+		own[i] = strings.ToLower(own[i])
+	}
+	if rewritten := x.Ident[0] + "." + strings.Join(own, "."); rewritten != original {
+		c.record(x.Position(), original, rewritten, tpl.ReasonParamsLowercase)
+	}
+}
+
+// hugoCond returns v if hugoIsZero considers it non-zero, and nil
+// otherwise. text/template's own truth test is then applied to that
+// result, which corrects the two cases that test gets wrong by itself: a
+// zero-value struct (e.g. a zero time.Time) and a typed nil pointer stored
+// in a non-nil interface. A non-zero v is passed through unchanged, so
+// with still binds dot to the value itself and and/or still return their
+// operands.
+func hugoCond(v interface{}) interface{} {
+	if hugoIsZero(v) {
+		return nil
+	}
+	return v
+}
+
+// hugoIsZero mirrors reflect.Value.IsZero, additionally unwrapping
+// pointers/interfaces (so a typed nil stored in a non-nil interface value,
+// e.g. T.NonEmptyInterfaceTypedNil in the tests, is treated as zero) and
+// preferring a value's own IsZero() bool method when it has one (e.g.
+// time.Time).
+func hugoIsZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return true
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.CanInterface() {
+		if zt, ok := rv.Interface().(interface{ IsZero() bool }); ok {
+			return zt.IsZero()
+		}
+	}
+
+	return rv.IsZero()
+}
+
+// hugoStrictParamsFuncName is the name of the function substituted for a
+// .Params field access when strict mode is enabled; see
+// maybeWrapStrictParamsCheck.
+const hugoStrictParamsFuncName = "__htH_strictParams"
+
+// hugoStrictParams walks keys into root, one map/struct lookup at a time,
+// and fails instead of silently yielding the zero value when a key is
+// missing. keys are the already lower-cased ones, and a map key must equal
+// one exactly; struct fields are matched case-insensitively. It backs strict mode: a .Params.FOO access that
+// even the case-insensitive rewrite cannot resolve is almost always a
+// typo, not an intentionally absent parameter.
+func hugoStrictParams(root interface{}, keys ...string) (interface{}, error) {
+	cur := reflect.ValueOf(root)
+
+	for _, key := range keys {
+		for cur.Kind() == reflect.Ptr || cur.Kind() == reflect.Interface {
+			cur = cur.Elem()
+		}
+
+		switch cur.Kind() {
+		case reflect.Map:
+			var found bool
+			for _, mk := range cur.MapKeys() {
+				if ks, ok := mk.Interface().(string); ok && ks == key {
+					cur = cur.MapIndex(mk)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("strict params: key %q not found", key)
+			}
+		case reflect.Struct:
+			fv := cur.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, key) })
+			if !fv.IsValid() {
+				return nil, fmt.Errorf("strict params: field %q not found", key)
+			}
+			cur = fv
+		default:
+			return nil, fmt.Errorf("strict params: cannot look up %q in a %s", key, cur.Kind())
+		}
+	}
+
+	if !cur.IsValid() {
+		return nil, errors.New("strict params: empty result")
+	}
+
+	return cur.Interface(), nil
+}
@@ -447,7 +447,7 @@ func TestInsertIsZeroFunc(t *testing.T) {
 
 	assert.NoError(h.MarkReady())
 
-	for _, name := range []string{"mytemplate.html", "mytexttemplate.txt"} {
+	for _, name := range []string{"mytemplate.html", "_text/mytexttemplate.txt"} {
 		tt, _ := d.Tmpl.Lookup(name)
 		result, err := tt.(tpl.TemplateExecutor).ExecuteToString(ctx)
 		assert.NoError(err)
@@ -549,3 +549,176 @@ func TestPartialReturn(t *testing.T) {
 	}
 
 }
+
+// Mirrors TestParamsKeysToLower, but with the case-sensitive opt-out
+// enabled: keys are looked up exactly as written, so only a template that
+// already matches its front matter's casing sees its params.
+func TestParamsKeysToLowerCaseSensitive(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{
+		"Params": map[string]interface{}{
+			"LOWER": "P1L",
+		},
+		"Site": map[string]interface{}{
+			"Params": map[string]interface{}{
+				"lower": "P2L",
+			},
+		},
+	}
+
+	templ, err := template.New("foo").Parse(`
+P1: {{ .Params.LOWER }}
+P2: {{ .Site.Params.LOWER }}
+`)
+	require.NoError(t, err)
+
+	c := newTemplateContext(createParseTreeLookup(templ), withCaseSensitiveParams(true))
+	c.applyTransformations(templ.Tree.Root)
+
+	var b bytes.Buffer
+	require.NoError(t, templ.Execute(&b, data))
+
+	result := b.String()
+
+	// .Params.LOWER matches the data's "LOWER" key verbatim, so it still
+	// resolves.
+	require.Contains(t, result, "P1: P1L")
+
+	// .Site.Params.LOWER does not match the data's lower-cased "lower"
+	// key, and with the rewrite disabled it is not silently folded to
+	// match, so it resolves to nothing.
+	require.NotContains(t, result, "P2: P2L")
+}
+
+// Strict mode turns a .Params access that even the case-insensitive
+// rewrite cannot resolve into a template execution error, instead of the
+// silent empty value a typo would otherwise produce.
+func TestParamsKeysToLowerStrict(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{
+		"Params": map[string]interface{}{
+			"lower": "P1L",
+		},
+	}
+
+	strictFuncs := template.FuncMap{hugoStrictParamsFuncName: hugoStrictParams}
+
+	templ, err := template.New("foo").Funcs(strictFuncs).Parse(`{{ .Params.LOWER }}`)
+	require.NoError(t, err)
+
+	c := newTemplateContext(createParseTreeLookup(templ), withStrictParams(true))
+	c.applyTransformations(templ.Tree.Root)
+
+	var b bytes.Buffer
+	require.NoError(t, templ.Execute(&b, data))
+	require.Contains(t, b.String(), "P1L")
+
+	badTempl, err := template.New("foo").Funcs(strictFuncs).Parse(`{{ .Params.NOTHERE }}`)
+	require.NoError(t, err)
+
+	c = newTemplateContext(createParseTreeLookup(badTempl), withStrictParams(true))
+	c.applyTransformations(badTempl.Tree.Root)
+
+	err = badTempl.Execute(&b, data)
+	require.Error(t, err)
+}
+
+// paramsTempl should be transformed once across all of its clones; the
+// rest are served from the cache.
+func TestApplyTemplateTransformersCache(t *testing.T) {
+	assert := require.New(t)
+
+	// A cache of its own, rather than globalTemplateTransformCache, so the
+	// assertion below is about this test's own calls and isn't at the
+	// mercy of what else happens to run against the process-wide singleton.
+	cache := newTemplateTransformCache()
+
+	templ, err := template.New("foo").Funcs(testFuncs).Parse(paramsTempl)
+	assert.NoError(err)
+
+	const clones = 5
+	for i := 0; i < clones; i++ {
+		cloned, err := templ.Clone()
+		assert.NoError(err)
+
+		_, err = applyTemplateTransformersCached(cache, templateUndefined, cloned.Tree, createParseTreeLookup(cloned))
+		assert.NoError(err)
+
+		var b bytes.Buffer
+		assert.NoError(cloned.Execute(&b, paramsData))
+		assert.Contains(b.String(), "P1: P1L")
+		assert.Contains(b.String(), "P2: P2L")
+	}
+
+	// All clones share the exact same (pre-transform) source, so they
+	// should have produced exactly one cache entry between them.
+	assert.Equal(1, cache.len())
+}
+
+// Two overlays of the same master define "main" with different content,
+// so they must not be confused for one another in the cache even though
+// both pass through applyTemplateTransformers for a template literally
+// named "main".
+func TestApplyTemplateTransformersCacheBlockOverlay(t *testing.T) {
+	assert := require.New(t)
+
+	master := `{{ block "main" . }}DEFAULT{{ end }}`
+
+	masterTpl, err := template.New("foo").Parse(master)
+	assert.NoError(err)
+
+	overlay1 := `{{ define "main" }}P1: {{ .Params.LOWER }}{{ end }}`
+	overlayTpl1, err := template.Must(masterTpl.Clone()).Parse(overlay1)
+	assert.NoError(err)
+	overlayTpl1 = overlayTpl1.Lookup("main")
+
+	overlay2 := `{{ define "main" }}P2: {{ .Params.LOWER }}{{ end }}`
+	overlayTpl2, err := template.Must(masterTpl.Clone()).Parse(overlay2)
+	assert.NoError(err)
+	overlayTpl2 = overlayTpl2.Lookup("main")
+
+	_, err = applyTemplateTransformers(templateUndefined, overlayTpl1.Tree, createParseTreeLookup(overlayTpl1))
+	assert.NoError(err)
+	_, err = applyTemplateTransformers(templateUndefined, overlayTpl2.Tree, createParseTreeLookup(overlayTpl2))
+	assert.NoError(err)
+
+	data := map[string]interface{}{"Params": map[string]interface{}{"lower": "L"}}
+
+	var b1, b2 bytes.Buffer
+	assert.NoError(overlayTpl1.Execute(&b1, data))
+	assert.NoError(overlayTpl2.Execute(&b2, data))
+
+	assert.Contains(b1.String(), "P1: L")
+	assert.Contains(b2.String(), "P2: L")
+}
+
+// applyTemplateTransformers records what it did to a template's parse tree,
+// so hugo --debug-templates can explain why a given key resolved (or
+// didn't).
+func TestApplyTemplateTransformersDiagnostics(t *testing.T) {
+	assert := require.New(t)
+
+	templ, err := template.New("foo").Funcs(testFuncs).Parse(`{{ .Params.LOWER }}{{ if .Inner }}{{ end }}`)
+	assert.NoError(err)
+
+	c, err := applyTemplateTransformers(templateUndefined, templ.Tree, createParseTreeLookup(templ))
+	assert.NoError(err)
+
+	assert.NotNil(c.Diagnostics)
+
+	var sawLowercase, sawInner bool
+	for _, tr := range c.Diagnostics.Transforms {
+		switch tr.Reason {
+		case tpl.ReasonParamsLowercase:
+			sawLowercase = true
+			assert.Equal(".Params.LOWER", tr.Original)
+			assert.Equal(".Params.lower", tr.Rewritten)
+		case tpl.ReasonInnerDetection:
+			sawInner = true
+		}
+	}
+	assert.True(sawLowercase, "expected a params-lowercase transform to be recorded")
+	assert.True(sawInner, "expected an inner-detection transform to be recorded")
+}
@@ -0,0 +1,29 @@
+// Copyright 2019 The Hugo Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tplimpl
+
+import (
+	"github.com/gohugoio/hugo/tpl"
+	"github.com/stretchr/testify/require"
+)
+
+// testDeps is a stand-in for the site-wide dependency container tests in
+// this package need just enough of: a ready-to-use tpl.TemplateHandler.
+type testDeps struct {
+	Tmpl tpl.TemplateHandler
+}
+
+func newD(assert *require.Assertions) *testDeps {
+	return &testDeps{Tmpl: New()}
+}